@@ -0,0 +1,38 @@
+// Copyright 2020 Thomas.Hoehenleitner [at] seerose.net
+// Use of this source code is governed by a license that can be found in the LICENSE file.
+
+// Package charDecoder provides a decoder for a raw, unframed byte stream: it
+// hands back every byte it reads unchanged. There is no trice protocol
+// framing and no format string to resolve, so it carries none of the %s /
+// ReadString plumbing the other decoders need for TRICE_S arguments.
+package charDecoder
+
+import (
+	"io"
+	"sync"
+
+	"github.com/rokath/trice/internal/decoder"
+	"github.com/rokath/trice/internal/id"
+)
+
+// charDecoder extends decoder.DecoderData. It has no char decoder specific state.
+type charDecoder struct {
+	decoder.DecoderData
+}
+
+// New creates and returns a char decoder instance.
+func New(w io.Writer, lut id.TriceIDLookUp, m *sync.RWMutex, li id.TriceIDLookUpLI, in io.Reader, endian bool) decoder.Decoder {
+	p := &charDecoder{}
+	p.W = w
+	p.Lut = lut
+	p.LutMutex = m
+	p.Li = li
+	p.In = in
+	p.Endian = endian
+	return p
+}
+
+// Read passes bytes from the inner reader through unchanged.
+func (p *charDecoder) Read(b []byte) (int, error) {
+	return p.In.Read(b)
+}
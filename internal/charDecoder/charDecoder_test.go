@@ -0,0 +1,36 @@
+package charDecoder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/tj/assert"
+
+	"github.com/rokath/trice/internal/decoder"
+)
+
+type testTable []struct {
+	in  []byte
+	exp string
+}
+
+func doCharTest(t *testing.T, endian bool, teTa testTable) {
+	for _, x := range teTa {
+		in := ioutil.NopCloser(bytes.NewBuffer(x.in))
+		var out bytes.Buffer
+		dec := New(&out, nil, nil, nil, in, endian)
+		buf := make([]byte, decoder.DefaultSize)
+		n, err := dec.Read(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, x.exp, string(buf[:n]))
+	}
+}
+
+func TestCHAR(t *testing.T) {
+	tt := testTable{
+		{[]byte{'A', 'B', 'C', '0', '1', '2'}, `ABC012`},
+		{[]byte{'a', 'b', '3', '4'}, `ab34`},
+	}
+	doCharTest(t, true, tt)
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Thomas.Hoehenleitner [at] seerose.net
+// Use of this source code is governed by a license that can be found in the LICENSE file.
+
+// Package cobsDecoder decodes trice streams framed with COBS (Consistent
+// Overhead Byte Stuffing): each trice is one COBS packet, terminated by a
+// single 0x00 delimiter byte.
+//
+// A decoded frame looks like:
+//
+//	[4 byte trice ID][optional 4 byte target timestamp][optional 4 byte location][fixed args...][%s args...]
+//
+// Non-string arguments are fixed 4 bytes wide, in format string order.
+// %s (TRICE_S) arguments cannot be fixed width, so they are appended after
+// all fixed width arguments, each read with decoder.ReadString.
+package cobsDecoder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rokath/trice/internal/decoder"
+	"github.com/rokath/trice/internal/id"
+)
+
+// cobsDecoder extends decoder.DecoderData with the COBS specific state.
+type cobsDecoder struct {
+	decoder.DecoderData
+	outBuf bytes.Buffer // outBuf collects what Emitter wrote for the current trice, so Read can hand it out.
+}
+
+// New creates and returns a COBS decoder instance.
+func New(w io.Writer, lut id.TriceIDLookUp, m *sync.RWMutex, li id.TriceIDLookUpLI, in io.Reader, endian bool) decoder.Decoder {
+	p := &cobsDecoder{}
+	p.W = w
+	p.Lut = lut
+	p.LutMutex = m
+	p.Li = li
+	p.In = in
+	p.Endian = endian
+	if "" != decoder.LineTemplate {
+		if e, err := decoder.NewTemplateEmitter(&p.outBuf, decoder.LineTemplate); nil == err {
+			p.Emitter = e
+			return p
+		}
+	}
+	p.Emitter = decoder.NewEmitter(&p.outBuf, decoder.OutFormat)
+	return p
+}
+
+// Read is the provided read method for the COBS decoder.
+func (p *cobsDecoder) Read(b []byte) (n int, err error) {
+	if 0 == len(p.B) {
+		if err = p.readTrice(); nil != err {
+			return 0, err
+		}
+	}
+	n = copy(b, p.B)
+	p.B = p.B[n:]
+	return n, nil
+}
+
+// readRawFrame reads raw COBS encoded bytes up to (excluding) the next 0x00
+// delimiter. It reads the inner stream one byte at a time via io.ReadFull,
+// so it works unchanged regardless of how the underlying io.Reader chunks
+// its data.
+func (p *cobsDecoder) readRawFrame() ([]byte, error) {
+	var enc []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(p.In, b); nil != err {
+			return nil, err
+		}
+		if 0 == b[0] {
+			return enc, nil
+		}
+		enc = append(enc, b[0])
+	}
+}
+
+// cobsDecode removes COBS byte stuffing from enc and returns the original frame.
+func cobsDecode(enc []byte) ([]byte, error) {
+	var out []byte
+	i := 0
+	for i < len(enc) {
+		code := int(enc[i])
+		if 0 == code {
+			return nil, fmt.Errorf("cobsDecoder: unexpected zero byte inside encoded frame")
+		}
+		i++
+		end := i + code - 1
+		if end > len(enc) {
+			return nil, fmt.Errorf("cobsDecoder: malformed frame")
+		}
+		out = append(out, enc[i:end]...)
+		i = end
+		if 0xff != code && i < len(enc) {
+			out = append(out, 0)
+		}
+	}
+	return out, nil
+}
+
+// readTrice decodes exactly one COBS framed trice from p.In and appends the
+// rendered line to p.B.
+func (p *cobsDecoder) readTrice() error {
+	enc, err := p.readRawFrame()
+	if nil != err {
+		return err
+	}
+	frame, err := cobsDecode(enc)
+	if nil != err {
+		return err
+	}
+	if len(frame) < 4 {
+		return fmt.Errorf("cobsDecoder: frame too short for a trice id")
+	}
+	offset := 0
+	triceID := p.ReadU32(frame[offset:])
+	offset += 4
+	decoder.LastTriceID = id.TriceID(triceID)
+
+	if decoder.TargetTimestampExists {
+		decoder.TargetTimestamp = p.ReadU32(frame[offset:])
+		offset += 4
+	}
+	if decoder.TargetLocationExists {
+		decoder.TargetLocation = p.ReadU32(frame[offset:])
+		offset += 4
+	}
+
+	if nil != p.LutMutex {
+		p.LutMutex.RLock()
+		defer p.LutMutex.RUnlock()
+	}
+	trice, ok := p.Lut[id.TriceID(triceID)]
+	if !ok {
+		return fmt.Errorf("unknown trice id %d", triceID)
+	}
+	p.Trice = trice
+
+	var file string
+	var line int
+	if li, ok := p.Li[id.TriceID(triceID)]; ok {
+		file = li.File
+		line = li.Line
+	}
+
+	fmtStrg, u := decoder.UReplaceN(p.Trice.Strg)
+	args := make([]interface{}, len(u))
+	for i, tag := range u {
+		if 5 == tag { // %s is read in a second pass, after all fixed width args
+			continue
+		}
+		if offset+4 > len(frame) {
+			return fmt.Errorf("cobsDecoder: frame too short for arg %d", i)
+		}
+		v := p.ReadU32(frame[offset:])
+		offset += 4
+		switch tag {
+		case 2: // float specifier
+			args[i] = math.Float32frombits(v)
+		case 3: // bool specifier (%t)
+			args[i] = 0 != v
+		case 1: // signed integer specifier
+			args[i] = int32(v)
+		default: // 0 unsigned, 4 pointer
+			args[i] = v
+		}
+	}
+	for i, tag := range u {
+		if 5 != tag {
+			continue
+		}
+		s, n, e := p.ReadString(frame[offset:])
+		if nil != e {
+			return e
+		}
+		args[i] = s
+		offset += n
+	}
+
+	p.CycleCounter++
+	ev := decoder.TriceEvent{
+		ID:              id.TriceID(triceID),
+		CycleCounter:    p.CycleCounter,
+		Location:        decoder.TargetLocation,
+		TargetTimestamp: decoder.TargetTimestamp,
+		HostTimestamp:   time.Now(),
+		File:            file,
+		Line:            line,
+		Args:            args,
+		FormatString:    fmtStrg,
+		Message:         fmt.Sprintf(fmtStrg, args...),
+	}
+	if err := p.Emitter.EmitTrice(ev); nil != err {
+		return err
+	}
+	p.B = append(p.B, p.outBuf.Bytes()...)
+	p.outBuf.Reset()
+	return nil
+}
@@ -0,0 +1,105 @@
+package cobsDecoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/tj/assert"
+
+	"github.com/rokath/trice/internal/decoder"
+	"github.com/rokath/trice/internal/id"
+)
+
+// cobsEncode applies COBS byte stuffing to data and appends the 0x00 frame
+// delimiter. It is only meant to build small test fixtures (runs between
+// zero bytes must stay well under 254 bytes, true for all tests here).
+func cobsEncode(data []byte) []byte {
+	var out []byte
+	start := 0
+	for i := 0; i <= len(data); i++ {
+		if i == len(data) || 0 == data[i] {
+			out = append(out, byte(i-start+1))
+			out = append(out, data[start:i]...)
+			start = i + 1
+		}
+	}
+	return append(out, 0)
+}
+
+// putU32 returns v as 4 little endian bytes, matching the endian=true test decoders below.
+func putU32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+// putU16 returns v as 2 little endian bytes.
+func putU16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, v)
+	return b
+}
+
+func TestCOBSFixedArgs(t *testing.T) {
+	lut := id.TriceIDLookUp{42: {Strg: `a=%d b=%u`}}
+	frame := append(putU32(42), putU32(0xffffffff)...) // a = -1 as signed
+	frame = append(frame, putU32(7)...)                 // b = 7
+	enc := cobsEncode(frame)
+	in := ioutil.NopCloser(bytes.NewReader(enc))
+	var out bytes.Buffer
+	dec := New(&out, lut, nil, nil, in, true)
+	buf := make([]byte, decoder.DefaultSize)
+	n, err := dec.(*cobsDecoder).Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "a=-1 b=7", string(buf[:n]))
+}
+
+// byteAtATimeReader returns at most one byte per Read call, regardless of
+// the size of the caller's buffer, so it exercises readRawFrame's handling
+// of an input stream that delivers bytes in smaller chunks than a frame.
+type byteAtATimeReader struct {
+	r io.Reader
+}
+
+func (s *byteAtATimeReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return s.r.Read(p)
+}
+
+// TestCOBSStringAcrossReadBoundary decodes a %s (TRICE_S) argument whose
+// length prefix and payload bytes arrive one at a time from the underlying
+// reader, i.e. split across many Read calls instead of delivered in one
+// chunk. A COBS frame is always delimited by a single 0x00 byte, so a
+// string cannot literally span two frames; this is the equivalent
+// boundary case for a framed decoder: the decoded string must still come
+// out correct when the frame's own bytes are fragmented on the way in.
+func TestCOBSStringAcrossReadBoundary(t *testing.T) {
+	lut := id.TriceIDLookUp{42: {Strg: `n=%d s=%s`}}
+	frame := append(putU32(42), putU32(3)...)
+	frame = append(frame, putU16(5)...)
+	frame = append(frame, []byte("hello")...)
+	enc := cobsEncode(frame)
+	in := ioutil.NopCloser(&byteAtATimeReader{r: bytes.NewReader(enc)})
+	var out bytes.Buffer
+	dec := New(&out, lut, nil, nil, in, true)
+	buf := make([]byte, decoder.DefaultSize)
+	n, err := dec.(*cobsDecoder).Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "n=3 s=hello", string(buf[:n]))
+}
+
+func TestCOBSUnknownID(t *testing.T) {
+	lut := id.TriceIDLookUp{}
+	enc := cobsEncode(putU32(99))
+	in := ioutil.NopCloser(bytes.NewReader(enc))
+	var out bytes.Buffer
+	dec := New(&out, lut, nil, nil, in, true)
+	buf := make([]byte, decoder.DefaultSize)
+	_, err := dec.(*cobsDecoder).Read(buf)
+	assert.NotNil(t, err)
+}
@@ -6,11 +6,13 @@ package decoder
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/rokath/trice/internal/id"
 )
@@ -19,15 +21,18 @@ const (
 	// defaultSize is the beginning receive and sync buffer size.
 	DefaultSize = 64 * 1014
 
-	// patNextFormatSpecifier is a regex to find next format specifier in a string (exclude %%*) and ignoring %s
+	// patNextFormatSpecifier is a regex to find next format specifier in a string (exclude %%*)
 	//
 	// https://regex101.com/r/BjiD5M/1
 	// Language C plus from language Go: %b, %F, %q
 	// Partial implemented: %hi, %hu, %ld, %li, %lf, %Lf, %Lu, %lli, %lld
-	// Not implemented: %s
 	//patNextFormatSpecifier = `(?:^|[^%])(%[0-9]*(-|c|d|e|E|f|F|g|G|h|i|l|L|o|O|p|q|u|x|X|n|b))`
 	//patNextFormatSpecifier = `%([+\-#'0-9\.0-9])*(c|d|e|E|f|F|g|G|h|i|l|L|o|O|p|q|u|x|X|n|b|t)` // assumes no `%%` inside string!
-	patNextFormatSpecifier = `%([+\-#'0-9\.0-9])*(b|c|d|e|f|g|E|F|G|h|i|l|L|n|o|O|p|q|t|u|x|X)` // assumes no `%%` inside string!
+	patNextFormatSpecifier = `%([+\-#'0-9\.0-9])*(b|c|d|e|f|g|E|F|G|h|i|l|L|n|o|O|p|q|s|t|u|x|X)` // assumes no `%%` inside string!
+
+	// patNextFormatSSpecifier is a regex to find next format s specifier in a string
+	// It does also match %%s positions!
+	patNextFormatSSpecifier = `%(\.[0-9]+)?s` // assumes no `%%` inside string!
 
 	// patNextFormatUSpecifier is a regex to find next format u specifier in a string
 	// It does also match %%u positions!
@@ -75,7 +80,12 @@ var (
 	// Unsigned if true, forces hex and in values printed as unsigned values.
 	Unsigned bool
 
+	// OutFormat selects the Emitter used by the decoders: "text" (default) or "json".
+	// The decoders read it in their New functions; no command line flag sets it.
+	OutFormat string
+
 	matchNextFormatSpecifier        = regexp.MustCompile(patNextFormatSpecifier)
+	matchNextFormatSSpecifier       = regexp.MustCompile(patNextFormatSSpecifier)
 	matchNextFormatUSpecifier       = regexp.MustCompile(patNextFormatUSpecifier)
 	matchNextFormatISpecifier       = regexp.MustCompile(patNextFormatISpecifier)
 	matchNextFormatXSpecifier       = regexp.MustCompile(patNextFormatXSpecifier)
@@ -106,22 +116,118 @@ type Decoder interface {
 
 // DecoderData is the common data struct for all decoders.
 type DecoderData struct {
-	W          io.Writer          // io.Stdout or the like
-	In         io.Reader          // in is the inner reader, which is used to get raw bytes
-	IBuf       []byte             // iBuf holds unprocessed (raw) bytes for interpretation.
-	B          []byte             // read buffer holds a single decoded COBS package, which can contain several trices.
-	Endian     bool               // endian is true for LittleEndian and false for BigEndian
-	TriceSize  int                // trice head and payload size as number of bytes
-	ParamSpace int                // trice payload size after head
-	SLen       int                // string length for TRICE_S
-	Lut        id.TriceIDLookUp   // id look-up map for translation
-	LutMutex   *sync.RWMutex      // to avoid concurrent map read and map write during map refresh triggered by filewatcher
-	Li         id.TriceIDLookUpLI // location information map
-	Trice      id.TriceFmt        // id.TriceFmt // received trice
+	W            io.Writer          // io.Stdout or the like
+	In           io.Reader          // in is the inner reader, which is used to get raw bytes
+	IBuf         []byte             // iBuf holds unprocessed (raw) bytes for interpretation.
+	B            []byte             // read buffer holds a single decoded COBS package, which can contain several trices.
+	Endian       bool               // endian is true for LittleEndian and false for BigEndian
+	TriceSize    int                // trice head and payload size as number of bytes
+	ParamSpace   int                // trice payload size after head
+	SLen         int                // string length for TRICE_S
+	Lut          id.TriceIDLookUp   // id look-up map for translation
+	LutMutex     *sync.RWMutex      // to avoid concurrent map read and map write during map refresh triggered by filewatcher
+	Li           id.TriceIDLookUpLI // location information map
+	Trice        id.TriceFmt        // id.TriceFmt // received trice
+	Emitter      Emitter            // Emitter renders a decoded trice, selected by OutFormat or LineTemplate
+	CycleCounter int                // CycleCounter counts successfully decoded trices, for the template context
 	//lastInnerRead     time.Time
 	//innerReadInterval time.Duration
 }
 
+// TriceEvent carries everything needed to render or serialize one decoded trice.
+type TriceEvent struct {
+	ID              id.TriceID    // ID is the trice id as found on the wire.
+	CycleCounter    int           // CycleCounter is the number of trices this decoder has decoded so far, this one included.
+	Location        uint32        // Location is the target location: 16 bit file id in high and 16 bit line number in low part.
+	TargetTimestamp uint32        // TargetTimestamp is the target specific timestamp value, if any.
+	HostTimestamp   time.Time     // HostTimestamp is the time this trice was decoded on the host.
+	File            string        // File is the source file name looked up by ID in the location information map, if known.
+	Line            int           // Line is the source line number looked up by ID in the location information map, if known.
+	Args            []interface{} // Args are the decoded, already typed arguments in format string order.
+	FormatString    string        // FormatString is the resolved, UReplaceN processed format string.
+	Message         string        // Message is FormatString rendered with Args, the human readable line.
+}
+
+// Emitter renders a decoded TriceEvent to its destination, e.g. as a human
+// readable text line or as one NDJSON object. It is selected with OutFormat.
+type Emitter interface {
+	EmitTrice(ev TriceEvent) error
+}
+
+// NewEmitter returns the Emitter matching outFormat ("text" or "json"),
+// writing to w. An unknown outFormat falls back to the text Emitter.
+func NewEmitter(w io.Writer, outFormat string) Emitter {
+	if "json" == outFormat {
+		return &NDJSONEmitter{W: w}
+	}
+	return &TextEmitter{W: w}
+}
+
+// TextEmitter is the default Emitter: it renders ev.Message prefixed with
+// the ShowID, ShowTargetTimestamp and LocationInformationFormatString
+// options, matching the historic hard-coded trice output.
+type TextEmitter struct {
+	W io.Writer
+}
+
+// EmitTrice writes ev as one human readable, optionally prefixed, text line.
+func (e *TextEmitter) EmitTrice(ev TriceEvent) error {
+	var prefix string
+	if "" != ShowID {
+		prefix += fmt.Sprintf(ShowID, ev.ID)
+	}
+	if "" != ShowTargetTimestamp {
+		prefix += fmt.Sprintf(ShowTargetTimestamp, ev.TargetTimestamp)
+	}
+	if "" != LocationInformationFormatString {
+		prefix += fmt.Sprintf(LocationInformationFormatString, ev.Location)
+	}
+	_, err := fmt.Fprint(e.W, prefix+ev.Message)
+	return err
+}
+
+// NDJSONEmitter is the Emitter for OutFormat "json": it writes one JSON
+// object per trice.
+type NDJSONEmitter struct {
+	W io.Writer
+}
+
+// jsonTrice is the NDJSON wire shape emitted by NDJSONEmitter. ShowID,
+// ShowTargetTimestamp and LocationInformationFormatString gate the
+// respective fields instead of prefixing the rendered text.
+type jsonTrice struct {
+	ID              *id.TriceID   `json:"id,omitempty"`
+	Location        *uint32       `json:"location,omitempty"`
+	TargetTimestamp *uint32       `json:"targetTimestamp,omitempty"`
+	Args            []interface{} `json:"args,omitempty"`
+	FormatString    string        `json:"formatString"`
+	Message         string        `json:"message"`
+}
+
+// EmitTrice writes ev as one NDJSON object, terminated with a newline.
+func (e *NDJSONEmitter) EmitTrice(ev TriceEvent) error {
+	jt := jsonTrice{
+		Args:         ev.Args,
+		FormatString: ev.FormatString,
+		Message:      ev.Message,
+	}
+	if "" != ShowID {
+		jt.ID = &ev.ID
+	}
+	if "" != ShowTargetTimestamp {
+		jt.TargetTimestamp = &ev.TargetTimestamp
+	}
+	if "" != LocationInformationFormatString {
+		jt.Location = &ev.Location
+	}
+	b, err := json.Marshal(jt)
+	if nil != err {
+		return err
+	}
+	_, err = fmt.Fprintln(e.W, string(b))
+	return err
+}
+
 // setInput allows switching the input stream to a different source.
 //
 // This function is for easier testing with cycle counters.
@@ -153,11 +259,31 @@ func (p *DecoderData) ReadU64(b []byte) uint64 {
 	return binary.BigEndian.Uint64(b)
 }
 
+// ReadString reads a TRICE_S dynamic string argument from b: a 16 bit
+// length value (in the stream's endianness, see ReadU16) followed by that
+// many raw bytes, and returns it decoded as UTF-8 together with the number
+// of bytes consumed from b (2 + length).
+//
+// The COBS decoder calls this once per %s specifier found by UReplaceN,
+// after all fixed-size params have been consumed from the trice payload.
+// The msgpack decoder reads %s arguments as MessagePack strings instead.
+func (p *DecoderData) ReadString(b []byte) (string, int, error) {
+	if len(b) < 2 {
+		return "", 0, fmt.Errorf("ReadString: need at least 2 bytes for the length prefix, got %d", len(b))
+	}
+	n := int(p.ReadU16(b))
+	if len(b) < 2+n {
+		return "", 0, fmt.Errorf("ReadString: need %d bytes for the string payload, got %d", n, len(b)-2)
+	}
+	return string(b[2 : 2+n]), 2 + n, nil
+}
+
 // UReplaceN checks all format specifier in i and replaces %nu with %nd and returns that result as o.
 //
 // If a replacement took place on position k u[k] is 1. Afterwards len(u) is amount of found format specifiers.
 // Additional, if UnsignedHex is true, for FormatX specifiers u[k] is also 1.
-// If a float format specifier was found at position k, u[k] is 2,
+// If a float format specifier was found at position k, u[k] is 2.
+// If a %s (dynamic, length-prefixed TRICE_S string) specifier was found at position k, u[k] is 5.
 // http://www.cplusplus.com/reference/cstdio/printf/
 // https://www.codingunit.com/printf-format-specifiers-format-conversions-and-formatted-output
 func UReplaceN(i string) (o string, u []int) {
@@ -182,6 +308,11 @@ func UReplaceN(i string) (o string, u []int) {
 			u = append(u, 3) // bool value
 			continue
 		}
+		locS := matchNextFormatSSpecifier.FindStringIndex(fm)
+		if nil != locS { // a %s or %.Ns found
+			u = append(u, 5) // dynamic string value
+			continue
+		}
 		locF := matchNextFormatFSpecifier.FindStringIndex(fm)
 		if nil != locF { // a %nf found
 			u = append(u, 2) // float value
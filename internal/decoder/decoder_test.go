@@ -0,0 +1,68 @@
+package decoder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestUReplaceNString(t *testing.T) {
+	o, u := UReplaceN("msg: %s")
+	assert.Equal(t, "msg: %s", o)
+	assert.Equal(t, []int{5}, u)
+}
+
+func TestUReplaceNStringPrecision(t *testing.T) {
+	o, u := UReplaceN("msg: %.8s")
+	assert.Equal(t, "msg: %.8s", o)
+	assert.Equal(t, []int{5}, u)
+}
+
+func TestUReplaceNMixedWithStringArg(t *testing.T) {
+	o, u := UReplaceN("a=%d b=%x c=%f d=%s")
+	assert.Equal(t, "a=%d b=%x c=%f d=%s", o)
+	assert.Equal(t, []int{1, 1, 2, 5}, u)
+}
+
+func TestReadString(t *testing.T) {
+	b := append([]byte{0x05, 0x00}, []byte("hello")...)
+	p := &DecoderData{Endian: true}
+	s, n, err := p.ReadString(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", s)
+	assert.Equal(t, 7, n)
+}
+
+// TestReadStringShortBuffer covers the case where b holds fewer payload bytes
+// than the length prefix of a %s argument announces. ReadString reports an
+// error; the COBS decoder returns that error for the frame.
+func TestReadStringShortBuffer(t *testing.T) {
+	b := append([]byte{0x05, 0x00}, []byte("hel")...) // only 3 of 5 payload bytes arrived
+	p := &DecoderData{Endian: true}
+	_, _, err := p.ReadString(b)
+	assert.NotNil(t, err)
+}
+
+func TestTextEmitter(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEmitter(&out, "text")
+	assert.Nil(t, e.EmitTrice(TriceEvent{ID: 42, Message: "hello"}))
+	assert.Equal(t, "hello", out.String())
+}
+
+func TestNDJSONEmitter(t *testing.T) {
+	ShowID = "id=%d "
+	defer func() { ShowID = "" }()
+	var out bytes.Buffer
+	e := NewEmitter(&out, "json")
+	assert.Nil(t, e.EmitTrice(TriceEvent{ID: 42, FormatString: "x=%d", Args: []interface{}{7}, Message: "x=7"}))
+	assert.Equal(t, `{"id":42,"args":[7],"formatString":"x=%d","message":"x=7"}`+"\n", out.String())
+}
+
+func TestNewEmitterUnknownFallsBackToText(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEmitter(&out, "bogus")
+	_, ok := e.(*TextEmitter)
+	assert.True(t, ok)
+}
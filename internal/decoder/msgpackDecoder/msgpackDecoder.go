@@ -0,0 +1,330 @@
+// Copyright 2020 Thomas.Hoehenleitner [at] seerose.net
+// Use of this source code is governed by a license that can be found in the LICENSE file.
+
+// Package msgpackDecoder decodes trice streams whose parameters are packed
+// with the MessagePack variable-length integer/float/string scheme.
+//
+// A msgpack framed trice looks like:
+//
+//	[varint trice ID][optional target timestamp][optional location][packed args...]
+//
+// Every value carries its own MessagePack type prefix, so each argument is
+// only as wide on the wire as its actual magnitude requires.
+package msgpackDecoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rokath/trice/internal/decoder"
+	"github.com/rokath/trice/internal/id"
+)
+
+// msgpackDecoder extends decoder.DecoderData with the msgpack specific state.
+type msgpackDecoder struct {
+	decoder.DecoderData
+	outBuf bytes.Buffer // outBuf collects what Emitter wrote for the current trice, so Read can hand it out.
+}
+
+// New creates and returns a msgpack decoder instance.
+//
+// lut is the trice id look-up map, m guards lut against concurrent refresh
+// by the filewatcher, li is the location information map and in is the raw
+// byte source. New follows the same constructor signature as the other
+// decoders, so it can be selected behind the same `-encoding` switch. w is
+// kept on DecoderData like the other decoders; the rendered trice text (or
+// JSON, depending on decoder.OutFormat) is only ever handed out through
+// Read, so it is not also duplicated into w.
+func New(w io.Writer, lut id.TriceIDLookUp, m *sync.RWMutex, li id.TriceIDLookUpLI, in io.Reader, endian bool) decoder.Decoder {
+	p := &msgpackDecoder{}
+	p.W = w
+	p.Lut = lut
+	p.LutMutex = m
+	p.Li = li
+	p.In = in
+	p.Endian = endian
+	if "" != decoder.LineTemplate {
+		if e, err := decoder.NewTemplateEmitter(&p.outBuf, decoder.LineTemplate); nil == err {
+			p.Emitter = e
+			return p
+		}
+	}
+	p.Emitter = decoder.NewEmitter(&p.outBuf, decoder.OutFormat)
+	return p
+}
+
+// Read is the provided read method for the msgpack decoder.
+//
+// p.B is filled with the next decoded trice line and handed out to b in
+// chunks of at most len(b) bytes, because the caller's buffer can be smaller
+// than one trice line.
+func (p *msgpackDecoder) Read(b []byte) (n int, err error) {
+	if 0 == len(p.B) {
+		if err = p.readTrice(); nil != err {
+			return 0, err
+		}
+	}
+	n = copy(b, p.B)
+	p.B = p.B[n:]
+	return n, nil
+}
+
+// readTrice decodes exactly one msgpack framed trice from p.In and appends
+// the rendered line to p.B.
+func (p *msgpackDecoder) readTrice() error {
+	triceID, err := p.readMsgpackUint()
+	if nil != err {
+		return err
+	}
+	decoder.LastTriceID = id.TriceID(triceID)
+
+	if decoder.TargetTimestampExists {
+		ts, e := p.readMsgpackUint()
+		if nil != e {
+			return e
+		}
+		decoder.TargetTimestamp = uint32(ts)
+	}
+	if decoder.TargetLocationExists {
+		loc, e := p.readMsgpackUint()
+		if nil != e {
+			return e
+		}
+		decoder.TargetLocation = uint32(loc)
+	}
+
+	if nil != p.LutMutex {
+		p.LutMutex.RLock()
+		defer p.LutMutex.RUnlock()
+	}
+	trice, ok := p.Lut[id.TriceID(triceID)]
+	if !ok {
+		return fmt.Errorf("unknown trice id %d", triceID)
+	}
+	p.Trice = trice
+
+	var file string
+	var line int
+	if li, ok := p.Li[id.TriceID(triceID)]; ok {
+		file = li.File
+		line = li.Line
+	}
+
+	fmtStrg, u := decoder.UReplaceN(p.Trice.Strg)
+	args := make([]interface{}, 0, len(u))
+	for _, tag := range u {
+		a, e := p.readMsgpackArg(tag)
+		if nil != e {
+			return e
+		}
+		args = append(args, a)
+	}
+
+	p.CycleCounter++
+	ev := decoder.TriceEvent{
+		ID:              id.TriceID(triceID),
+		CycleCounter:    p.CycleCounter,
+		Location:        decoder.TargetLocation,
+		TargetTimestamp: decoder.TargetTimestamp,
+		HostTimestamp:   time.Now(),
+		File:            file,
+		Line:            line,
+		Args:            args,
+		FormatString:    fmtStrg,
+		Message:         fmt.Sprintf(fmtStrg, args...),
+	}
+	if err := p.Emitter.EmitTrice(ev); nil != err {
+		return err
+	}
+	p.B = append(p.B, p.outBuf.Bytes()...)
+	p.outBuf.Reset()
+	return nil
+}
+
+// readMsgpackArg reads a single packed value from p.In according to tag,
+// the per-specifier hint produced by decoder.UReplaceN, and converts it
+// into the Go type fmt.Sprintf expects for that specifier.
+func (p *msgpackDecoder) readMsgpackArg(tag int) (interface{}, error) {
+	switch tag {
+	case 2: // float specifier
+		return p.readMsgpackFloat()
+	case 3: // bool specifier (%t)
+		return p.readMsgpackBool()
+	case 5: // dynamic string specifier (%s, TRICE_S)
+		return p.readMsgpackString()
+	case 1: // signed integer specifier
+		return p.readMsgpackInt()
+	default: // 0 and 4: unsigned integer or pointer specifier
+		v, err := p.readMsgpackUint()
+		return v, err
+	}
+}
+
+// readByte reads and returns the next raw byte from p.In.
+func (p *msgpackDecoder) readByte() (byte, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(p.In, b); nil != err {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readN reads and returns the next n raw bytes from p.In.
+func (p *msgpackDecoder) readN(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(p.In, b); nil != err {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readMsgpackUint reads a MessagePack encoded non-negative integer.
+//
+// MessagePack multi-byte integers are always big-endian by specification,
+// independent of the stream endianness used for the fixed-width decoders.
+func (p *msgpackDecoder) readMsgpackUint() (uint64, error) {
+	h, err := p.readByte()
+	if nil != err {
+		return 0, err
+	}
+	switch {
+	case h <= 0x7f: // positive fixnum
+		return uint64(h), nil
+	case 0xcc == h: // uint8
+		b, e := p.readN(1)
+		return uint64(b[0]), e
+	case 0xcd == h: // uint16
+		b, e := p.readN(2)
+		return uint64(binary.BigEndian.Uint16(b)), e
+	case 0xce == h: // uint32
+		b, e := p.readN(4)
+		return uint64(binary.BigEndian.Uint32(b)), e
+	case 0xcf == h: // uint64
+		b, e := p.readN(8)
+		return binary.BigEndian.Uint64(b), e
+	}
+	return 0, fmt.Errorf("msgpackDecoder: 0x%02x is not a valid unsigned integer prefix", h)
+}
+
+// readMsgpackInt reads a MessagePack encoded integer, signed or unsigned.
+func (p *msgpackDecoder) readMsgpackInt() (int64, error) {
+	h, err := p.readByte()
+	if nil != err {
+		return 0, err
+	}
+	switch {
+	case h <= 0x7f: // positive fixnum
+		return int64(h), nil
+	case h >= 0xe0: // negative fixnum: -32..-1
+		return int64(int8(h)), nil
+	case 0xd0 == h: // int8
+		b, e := p.readN(1)
+		return int64(int8(b[0])), e
+	case 0xd1 == h: // int16
+		b, e := p.readN(2)
+		return int64(int16(binary.BigEndian.Uint16(b))), e
+	case 0xd2 == h: // int32
+		b, e := p.readN(4)
+		return int64(int32(binary.BigEndian.Uint32(b))), e
+	case 0xd3 == h: // int64
+		b, e := p.readN(8)
+		return int64(binary.BigEndian.Uint64(b)), e
+	case h >= 0xcc && h <= 0xcf: // unsigned typed ints are also valid in a signed slot
+		switch h {
+		case 0xcc:
+			b, e := p.readN(1)
+			return int64(b[0]), e
+		case 0xcd:
+			b, e := p.readN(2)
+			return int64(binary.BigEndian.Uint16(b)), e
+		case 0xce:
+			b, e := p.readN(4)
+			return int64(binary.BigEndian.Uint32(b)), e
+		default: // 0xcf
+			b, e := p.readN(8)
+			return int64(binary.BigEndian.Uint64(b)), e
+		}
+	}
+	return 0, fmt.Errorf("msgpackDecoder: 0x%02x is not a valid integer prefix", h)
+}
+
+// readMsgpackFloat reads a MessagePack encoded float32 (0xca) or float64 (0xcb).
+func (p *msgpackDecoder) readMsgpackFloat() (float64, error) {
+	h, err := p.readByte()
+	if nil != err {
+		return 0, err
+	}
+	switch h {
+	case 0xca: // float32
+		b, e := p.readN(4)
+		if nil != e {
+			return 0, e
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb: // float64
+		b, e := p.readN(8)
+		if nil != e {
+			return 0, e
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	}
+	return 0, fmt.Errorf("msgpackDecoder: 0x%02x is not a valid float prefix", h)
+}
+
+// readMsgpackBool reads a MessagePack encoded bool (0xc2 false, 0xc3 true).
+func (p *msgpackDecoder) readMsgpackBool() (bool, error) {
+	h, err := p.readByte()
+	if nil != err {
+		return false, err
+	}
+	switch h {
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	}
+	return false, fmt.Errorf("msgpackDecoder: 0x%02x is not a valid bool prefix", h)
+}
+
+// readMsgpackString reads a MessagePack encoded str8 (0xd9), str16 (0xda) or
+// str32 (0xdb) value and returns its UTF-8 payload. It is used for the
+// TRICE_S dynamic string argument.
+func (p *msgpackDecoder) readMsgpackString() (string, error) {
+	h, err := p.readByte()
+	if nil != err {
+		return "", err
+	}
+	var n int
+	switch h {
+	case 0xd9: // str8
+		b, e := p.readN(1)
+		if nil != e {
+			return "", e
+		}
+		n = int(b[0])
+	case 0xda: // str16
+		b, e := p.readN(2)
+		if nil != e {
+			return "", e
+		}
+		n = int(binary.BigEndian.Uint16(b))
+	case 0xdb: // str32
+		b, e := p.readN(4)
+		if nil != e {
+			return "", e
+		}
+		n = int(binary.BigEndian.Uint32(b))
+	default:
+		return "", fmt.Errorf("msgpackDecoder: 0x%02x is not a valid str prefix", h)
+	}
+	s, e := p.readN(n)
+	if nil != e {
+		return "", e
+	}
+	return string(s), nil
+}
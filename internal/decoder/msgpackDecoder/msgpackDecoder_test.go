@@ -0,0 +1,96 @@
+package msgpackDecoder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/tj/assert"
+
+	"github.com/rokath/trice/internal/decoder"
+	"github.com/rokath/trice/internal/id"
+)
+
+// testTable is one round-trip test case: in is the raw msgpack framed trice,
+// fmtStrg is the registered format string for the trice id used in in, and
+// exp is the expected rendered line.
+type testTable []struct {
+	in      []byte
+	fmtStrg string
+	exp     string
+}
+
+// doMsgpackTest feeds every in through a fresh decoder instance and checks
+// the rendered output against exp.
+func doMsgpackTest(t *testing.T, endian bool, teTa testTable) {
+	for _, x := range teTa {
+		lut := id.TriceIDLookUp{42: {Strg: x.fmtStrg}}
+		in := ioutil.NopCloser(bytes.NewBuffer(x.in))
+		var out bytes.Buffer
+		dec := New(&out, lut, nil, nil, in, endian)
+		buf := make([]byte, decoder.DefaultSize)
+		var act string
+		for {
+			n, err := dec.(*msgpackDecoder).Read(buf)
+			act += string(buf[:n])
+			if nil != err {
+				break
+			}
+		}
+		assert.Equal(t, x.exp, act)
+	}
+}
+
+func TestMsgpackFixnum(t *testing.T) {
+	tt := testTable{
+		// id=42 (positive fixnum), arg 5 (positive fixnum), arg -1 (negative fixnum)
+		{[]byte{0x2a, 0x05, 0xff}, `a=%d b=%d`, `a=5 b=-1`},
+	}
+	doMsgpackTest(t, true, tt)
+}
+
+func TestMsgpackTypedInts(t *testing.T) {
+	tt := testTable{
+		// id=42, uint16(300) = 0xcd 0x01 0x2c, int8(-100) = 0xd0 0x9c
+		{[]byte{0x2a, 0xcd, 0x01, 0x2c, 0xd0, 0x9c}, `u=%u i=%d`, `u=300 i=-100`},
+	}
+	doMsgpackTest(t, true, tt)
+}
+
+func TestMsgpackMixedWithString(t *testing.T) {
+	tt := testTable{
+		// id=42, n=7 (fixnum), str8 "trice!" (6 bytes), x=255 -> uint8
+		{append([]byte{0x2a, 0x07, 0xd9, 0x06}, append([]byte("trice!"), 0xcc, 0xff)...),
+			`n=%d s=%s x=%x`, `n=7 s=trice! x=ff`},
+	}
+	doMsgpackTest(t, true, tt)
+}
+
+func TestMsgpackTriceEventLocationAndCycle(t *testing.T) {
+	decoder.LineTemplate = "{{.File}}:{{.Line}} #{{.CycleCounter}} {{.Message}}"
+	defer func() { decoder.LineTemplate = "" }()
+
+	lut := id.TriceIDLookUp{42: {Strg: `n=%d`}}
+	li := id.TriceIDLookUpLI{42: {File: "main.c", Line: 17}}
+	in := ioutil.NopCloser(bytes.NewBuffer([]byte{0x2a, 0x01, 0x2a, 0x02}))
+	var out bytes.Buffer
+	dec := New(&out, lut, nil, li, in, true)
+	buf := make([]byte, decoder.DefaultSize)
+	var act string
+	for {
+		n, err := dec.(*msgpackDecoder).Read(buf)
+		act += string(buf[:n])
+		if nil != err {
+			break
+		}
+	}
+	assert.Equal(t, "main.c:17 #1 n=1main.c:17 #2 n=2", act)
+}
+
+func TestMsgpackFloat(t *testing.T) {
+	tt := testTable{
+		// id=42, float32(1.5) = 0xca 0x3f 0xc0 0x00 0x00
+		{[]byte{0x2a, 0xca, 0x3f, 0xc0, 0x00, 0x00}, `f=%f`, `f=1.500000`},
+	}
+	doMsgpackTest(t, true, tt)
+}
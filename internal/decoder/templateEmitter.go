@@ -0,0 +1,117 @@
+// Copyright 2020 Thomas.Hoehenleitner [at] seerose.net
+// Use of this source code is governed by a license that can be found in the LICENSE file.
+
+package decoder
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rokath/trice/internal/id"
+)
+
+// LineTemplate is the raw text/template text for every decoded trice; no
+// command line flag sets it. If not "", the decoders' New functions parse it
+// with NewTemplateEmitter, and it takes precedence over OutFormat and the
+// ShowID, ShowTargetTimestamp and LocationInformationFormatString prefixing
+// done by TextEmitter. If it does not parse, the decoders fall back to the
+// OutFormat Emitter without reporting the error.
+var LineTemplate string
+
+// TemplateContext is the data made available inside a LineTemplate template
+// for every decoded trice.
+type TemplateContext struct {
+	ID              id.TriceID    // ID is the trice id as found on the wire.
+	CycleCounter    int           // CycleCounter is the number of trices this decoder has decoded so far, this one included.
+	TargetTimestamp uint32        // TargetTimestamp is the target specific timestamp value, if any.
+	HostTimestamp   time.Time     // HostTimestamp is the time this trice was decoded on the host.
+	File            string        // File is the source file name resolved from the trice location, if known.
+	Line            int           // Line is the source line number resolved from the trice location, if known.
+	Level           string        // Level is the channel prefix (err, wrn, ...) found at the start of the format string, if any.
+	Message         string        // Message is the rendered trice line.
+	Args            []interface{} // Args are the decoded, already typed arguments in format string order.
+}
+
+// TemplateEmitter is the Emitter for LineTemplate: it executes a user
+// supplied text/template once per decoded trice instead of the hard-coded
+// ShowID / ShowTargetTimestamp / LocationInformationFormatString prefixing
+// done by TextEmitter.
+type TemplateEmitter struct {
+	W   io.Writer
+	Tpl *template.Template
+}
+
+// NewTemplateEmitter parses tmplText with the helper funcs below and
+// returns an Emitter executing it once per decoded trice.
+func NewTemplateEmitter(w io.Writer, tmplText string) (Emitter, error) {
+	t, err := template.New("lineTemplate").Funcs(templateFuncs).Parse(tmplText)
+	if nil != err {
+		return nil, fmt.Errorf("NewTemplateEmitter: %w", err)
+	}
+	return &TemplateEmitter{W: w, Tpl: t}, nil
+}
+
+// EmitTrice renders ev through the parsed template.
+func (e *TemplateEmitter) EmitTrice(ev TriceEvent) error {
+	ctx := TemplateContext{
+		ID:              ev.ID,
+		CycleCounter:    ev.CycleCounter,
+		TargetTimestamp: ev.TargetTimestamp,
+		HostTimestamp:   ev.HostTimestamp,
+		File:            ev.File,
+		Line:            ev.Line,
+		Level:           triceLevel(ev.FormatString),
+		Message:         ev.Message,
+		Args:            ev.Args,
+	}
+	return e.Tpl.Execute(e.W, ctx)
+}
+
+// triceChannels are the trice channel tags recognized as a Level by triceLevel.
+var triceChannels = map[string]bool{
+	"att": true, "dbg": true, "diag": true, "err": true, "inf": true,
+	"isr": true, "rtr": true, "sig": true, "tim": true, "wrn": true,
+	"def": true, "not": true,
+}
+
+// triceLevel derives the trice channel level (err, wrn, att, ...) from the
+// leading "<level>:" tag convention used in trice format strings.
+func triceLevel(fmtStrg string) string {
+	i := strings.IndexByte(fmtStrg, ':')
+	if i < 0 {
+		return ""
+	}
+	level := fmtStrg[:i]
+	if triceChannels[level] {
+		return level
+	}
+	return ""
+}
+
+// templateFuncs are the helper funcs available inside a LineTemplate template.
+var templateFuncs = template.FuncMap{
+	"printf": fmt.Sprintf,
+	"hex": func(v interface{}) string {
+		return fmt.Sprintf("%x", v)
+	},
+	"pad": func(width int, s string) string {
+		if len(s) >= width {
+			return s
+		}
+		return s + strings.Repeat(" ", width-len(s))
+	},
+	// col* mirror the foreground color helpers from the col package.
+	"colRed":     func(s string) string { return col(31, s) },
+	"colGreen":   func(s string) string { return col(32, s) },
+	"colYellow":  func(s string) string { return col(33, s) },
+	"colBlue":    func(s string) string { return col(34, s) },
+	"colDefault": func(s string) string { return col(0, s) },
+}
+
+// col wraps s in the ANSI escape sequence for the given foreground color code.
+func col(code int, s string) string {
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", code, s)
+}
@@ -0,0 +1,47 @@
+package decoder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestTemplateEmitter(t *testing.T) {
+	var out bytes.Buffer
+	e, err := NewTemplateEmitter(&out, "{{.Level}}|{{.ID}}|{{.Message}}")
+	assert.Nil(t, err)
+	err = e.EmitTrice(TriceEvent{ID: 42, FormatString: "wrn:oops %d", Message: "oops 7"})
+	assert.Nil(t, err)
+	assert.Equal(t, "wrn|42|oops 7", out.String())
+}
+
+func TestTemplateEmitterHelperFuncs(t *testing.T) {
+	var out bytes.Buffer
+	e, err := NewTemplateEmitter(&out, `{{pad 8 "ab"}}|{{hex 255}}|{{printf "%d-%d" 1 2}}`)
+	assert.Nil(t, err)
+	err = e.EmitTrice(TriceEvent{})
+	assert.Nil(t, err)
+	assert.Equal(t, "ab      |ff|1-2", out.String())
+}
+
+func TestTemplateEmitterLocationAndCycle(t *testing.T) {
+	var out bytes.Buffer
+	e, err := NewTemplateEmitter(&out, "{{.File}}:{{.Line}} #{{.CycleCounter}}")
+	assert.Nil(t, err)
+	err = e.EmitTrice(TriceEvent{File: "main.c", Line: 17, CycleCounter: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, "main.c:17 #3", out.String())
+}
+
+func TestTriceLevelUnknownPrefixIsEmpty(t *testing.T) {
+	assert.Equal(t, "", triceLevel("no channel prefix here"))
+	assert.Equal(t, "", triceLevel("foo:bar"))
+	assert.Equal(t, "err", triceLevel("err:failed"))
+}
+
+func TestNewTemplateEmitterInvalidTemplate(t *testing.T) {
+	var out bytes.Buffer
+	_, err := NewTemplateEmitter(&out, "{{.Nope")
+	assert.NotNil(t, err)
+}